@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ModelAction is a bulk operation that can be run against the primary keys
+// of the rows selected on a model's list view.
+type ModelAction func(ids []int, db *sql.DB) error
+
+// RegisterAction registers a named bulk action on m. It shows up alongside
+// the built-in "delete" action in the actions dropdown on the list view.
+func (m *model) RegisterAction(name string, fn ModelAction) {
+	if m.actions == nil {
+		m.actions = map[string]ModelAction{}
+	}
+	m.actions[name] = fn
+}
+
+// actionNames lists the actions available for m, "delete" first.
+func (m *model) actionNames() []string {
+	names := []string{"delete"}
+	for name := range m.actions {
+		names = append(names, name)
+	}
+	return names
+}
+
+// dependentRef describes rows in another model that reference one of the
+// rows about to be deleted through a foreign key.
+type dependentRef struct {
+	ModelName string
+	Count     int
+}
+
+// dependentsOf returns, for each other registered model with a foreign key
+// pointing at target, how many of its rows reference any of ids.
+func (a *Admin) dependentsOf(target *model, ids []int) ([]dependentRef, error) {
+	refs := []dependentRef{}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = a.dialect.Placeholder(i + 1)
+		args[i] = id
+	}
+
+	for _, m := range a.models {
+		for _, field := range m.fields {
+			fk, ok := field.(*ForeignKeyField)
+			if !ok || fk.model != target {
+				continue
+			}
+
+			q := fmt.Sprintf(
+				"SELECT COUNT(*) FROM %v WHERE %v IN (%v)",
+				m.tableName, fk.Attrs().columnName, strings.Join(placeholders, ", "),
+			)
+			var count int
+			if err := a.db.QueryRow(q, args...).Scan(&count); err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				refs = append(refs, dependentRef{ModelName: m.Name, Count: count})
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+func parseSelectedIDs(req *http.Request) ([]int, error) {
+	ids := []int{}
+	for _, raw := range req.Form["ids"] {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// deleteRows removes the given ids from target's table inside a
+// transaction bracketed by target's optional BeforeDelete/AfterDelete
+// hooks; an error from either hook rolls the transaction back.
+func (a *Admin) deleteRows(target *model, ids []int) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if hook, ok := target.instance.(BeforeDeleter); ok {
+		if err := hook.BeforeDelete(tx, ids); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = a.dialect.Placeholder(i + 1)
+		args[i] = id
+	}
+
+	q := fmt.Sprintf("DELETE FROM %v WHERE id IN (%v)", target.tableName, strings.Join(placeholders, ", "))
+	if _, err := tx.Exec(q, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if hook, ok := target.instance.(AfterDeleter); ok {
+		if err := hook.AfterDelete(tx, ids); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// handleAction runs a bulk action (built-in "delete" or one registered with
+// RegisterAction) against the rows selected on a model's list view.
+func (a *Admin) handleAction(rw http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	slug := vars["slug"]
+
+	target, ok := a.models[slug]
+	if !ok {
+		http.NotFound(rw, req)
+		return
+	}
+
+	req.ParseForm()
+	sess := a.getUserSession(req)
+
+	actionName := req.Form.Get("action")
+	requiredPerm := "change"
+	if actionName == "delete" {
+		requiredPerm = "delete"
+	}
+	if !a.hasPermission(sess.Role, slug, requiredPerm) {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	ids, err := parseSelectedIDs(req)
+	if err != nil || len(ids) == 0 {
+		sess.addMessage("error", "No rows were selected.")
+		http.Redirect(rw, req, a.modelURL(slug, ""), 302)
+		return
+	}
+
+	if actionName == "delete" {
+		if req.Form.Get("confirmed") != "true" {
+			refs, err := a.dependentsOf(target, ids)
+			if err != nil {
+				fmt.Println(err)
+				http.Redirect(rw, req, a.modelURL(slug, ""), 302)
+				return
+			}
+
+			a.render(rw, req, "delete_confirm.html", map[string]interface{}{
+				"name":       target.Name,
+				"slug":       slug,
+				"ids":        ids,
+				"dependents": refs,
+				"csrf":       template.HTML(csrfField(sess)),
+			})
+			return
+		}
+
+		if err := a.deleteRows(target, ids); err != nil {
+			fmt.Println(err)
+			sess.addMessage("error", "Could not delete the selected rows.")
+		} else {
+			sess.addMessage("success", fmt.Sprintf("%v row(s) deleted.", len(ids)))
+		}
+
+		http.Redirect(rw, req, a.modelURL(slug, ""), 302)
+		return
+	}
+
+	fn, ok := target.actions[actionName]
+	if !ok {
+		sess.addMessage("error", fmt.Sprintf("Unknown action %q.", actionName))
+		http.Redirect(rw, req, a.modelURL(slug, ""), 302)
+		return
+	}
+
+	if err := fn(ids, a.db); err != nil {
+		fmt.Println(err)
+		sess.addMessage("error", err.Error())
+	} else {
+		sess.addMessage("success", fmt.Sprintf("Action %q ran on %v row(s).", actionName, len(ids)))
+	}
+
+	http.Redirect(rw, req, a.modelURL(slug, ""), 302)
+}
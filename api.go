@@ -0,0 +1,354 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// APIToken authenticates a JSON API client without going through the cookie
+// based login form, so decoupled frontends don't need to scrape it.
+type APIToken struct {
+	Token    string
+	Username string
+	Role     string
+}
+
+// authenticateToken resolves the caller's role from an
+// "Authorization: Bearer <token>" header, returning ok=false if it's
+// missing or doesn't match a configured APIToken.
+func (a *Admin) authenticateToken(req *http.Request) (role string, ok bool) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	for _, t := range a.Tokens {
+		if t.Token == token {
+			return t.Role, true
+		}
+	}
+	return "", false
+}
+
+// authenticate resolves the caller's role from either a session cookie or a
+// bearer token, returning ok=false if neither is present or valid.
+func (a *Admin) authenticate(req *http.Request) (role string, ok bool) {
+	if sess := a.getUserSession(req); sess != nil {
+		return sess.Role, true
+	}
+	return a.authenticateToken(req)
+}
+
+// apiWrapper authenticates read-only requests (session cookie or bearer
+// token), checks the caller's role has view rights on the requested model,
+// and writes a JSON error instead of redirecting to the login page.
+func (a *Admin) apiWrapper(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		role, ok := a.authenticate(req)
+		if !ok {
+			writeJSONError(rw, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !a.hasPermission(role, mux.Vars(req)["slug"], "view") {
+			writeJSONError(rw, http.StatusForbidden, "insufficient permissions")
+			return
+		}
+		h.ServeHTTP(rw, req)
+	}
+}
+
+// apiWriteWrapper authenticates mutating requests (POST/PUT/DELETE) and
+// checks the caller's role has the matching add/change/delete right on the
+// requested model. These only accept a bearer token: the session cookie is
+// deliberately rejected here, since the JSON API has no CSRF protection and
+// a cookie would otherwise let any page the admin has open trigger state
+// changes.
+func (a *Admin) apiWriteWrapper(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		role, ok := a.authenticateToken(req)
+		if !ok {
+			writeJSONError(rw, http.StatusUnauthorized, "a bearer token is required for this request")
+			return
+		}
+
+		action := "change"
+		switch req.Method {
+		case "POST":
+			action = "add"
+		case "DELETE":
+			action = "delete"
+		}
+		if !a.hasPermission(role, mux.Vars(req)["slug"], action) {
+			writeJSONError(rw, http.StatusForbidden, "insufficient permissions")
+			return
+		}
+
+		h.ServeHTTP(rw, req)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, body interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func writeJSONError(rw http.ResponseWriter, status int, message string) {
+	writeJSON(rw, status, map[string]string{"error": message})
+}
+
+// List returns up to pageSize rows (1-indexed page) from m matching the
+// search term q, plus the total number of matching rows. It's the JSON-API
+// equivalent of what handleList renders as HTML, and pages in SQL rather
+// than loading every matching row into memory.
+func (a *Admin) List(m *model, q string, page, pageSize int) ([]map[string]interface{}, int, error) {
+	rows, total, err := a.queryModelFiltered(m, listOptions{q: q, page: page, perPage: pageSize})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cols := m.listTableColumns()
+	out := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		rowMap := map[string]interface{}{}
+		for i, col := range cols {
+			rowMap[col] = row[i]
+		}
+		out = append(out, rowMap)
+	}
+
+	return out, total, nil
+}
+
+// Get returns a single row of m by id as a column name -> value map.
+func (a *Admin) Get(m *model, id int) (map[string]interface{}, error) {
+	return a.querySingleModel(m, id)
+}
+
+// Save validates values against m's fields and, if valid, inserts (id == 0)
+// or updates (id != 0) the row, returning the affected id. Save is the pure,
+// non-HTTP core of the JSON API's writes. The HTML handleSave handler does
+// not call Save: it runs its own per-field validation (including file
+// uploads) and shares only m.validate and saveRow with it.
+func (a *Admin) Save(m *model, values map[string]string, id int) (int, map[string]string) {
+	data := map[string]interface{}{}
+	errs := map[string]string{}
+
+	for _, fieldName := range m.fieldNames() {
+		if fieldName == "id" {
+			continue
+		}
+		field := m.fieldByName(fieldName)
+		rawValue := values[fieldName]
+
+		val, err := field.Validate(rawValue)
+		if err != nil {
+			errs[fieldName] = err.Error()
+			continue
+		}
+		if rawValue == "" {
+			continue
+		}
+		data[fieldName] = val
+	}
+
+	if len(errs) > 0 {
+		return 0, errs
+	}
+
+	if crossErrs := m.validate(data); len(crossErrs) > 0 {
+		return 0, crossErrs
+	}
+
+	savedID, err := a.saveRow(m, data, id)
+	if err != nil {
+		return 0, map[string]string{"_": err.Error()}
+	}
+	return savedID, nil
+}
+
+// saveRow inserts (id == 0) or updates (id != 0) m's table with already
+// validated column -> value data, returning the affected id. The write runs
+// inside a transaction bracketed by m's optional BeforeSave/AfterSave hooks;
+// an error from either hook rolls the transaction back.
+func (a *Admin) saveRow(m *model, data map[string]interface{}, id int) (int, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if hook, ok := m.instance.(BeforeSaver); ok {
+		if err := hook.BeforeSave(tx, data); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	savedID, err := a.execSave(tx, m, data, id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if hook, ok := m.instance.(AfterSaver); ok {
+		if err := hook.AfterSave(tx, savedID, data); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return savedID, nil
+}
+
+// execSave builds and runs the INSERT/UPDATE for data against m's table,
+// using tx so it participates in the caller's transaction.
+func (a *Admin) execSave(tx *sql.Tx, m *model, data map[string]interface{}, id int) (int, error) {
+	changedCols := make([]string, len(data))
+	changedData := make([]interface{}, len(data))
+	i := 0
+	for key, value := range data {
+		col := key
+		if a.NameTransform != nil {
+			col = a.NameTransform(key)
+		}
+		if id != 0 {
+			col = fmt.Sprintf("%v = %v", col, a.dialect.Placeholder(i+1))
+		}
+		changedCols[i] = col
+		changedData[i] = value
+		i++
+	}
+
+	if id != 0 {
+		q := fmt.Sprintf("UPDATE %v SET %v WHERE id = %v", m.tableName, strings.Join(changedCols, ", "), id)
+		if _, err := tx.Exec(q, changedData...); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+
+	marks := make([]string, len(data))
+	for i := range marks {
+		marks[i] = a.dialect.Placeholder(i + 1)
+	}
+	q := fmt.Sprintf("INSERT INTO %v(%v) VALUES(%v)%v", m.tableName, strings.Join(changedCols, ", "), strings.Join(marks, ", "), a.dialect.InsertReturningSuffix())
+
+	if suffix := a.dialect.InsertReturningSuffix(); suffix != "" {
+		var newID int
+		if err := tx.QueryRow(q, changedData...).Scan(&newID); err != nil {
+			return 0, err
+		}
+		return newID, nil
+	}
+
+	res, err := tx.Exec(q, changedData...)
+	if err != nil {
+		return 0, err
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(lastID), nil
+}
+
+func (a *Admin) handleAPIList(rw http.ResponseWriter, req *http.Request) {
+	m, ok := a.models[mux.Vars(req)["slug"]]
+	if !ok {
+		writeJSONError(rw, http.StatusNotFound, "unknown model")
+		return
+	}
+
+	req.ParseForm()
+	page, _ := strconv.Atoi(req.Form.Get("page"))
+	pageSize, _ := strconv.Atoi(req.Form.Get("page_size"))
+
+	rows, total, err := a.List(m, req.Form.Get("q"), page, pageSize)
+	if err != nil {
+		writeJSONError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"results": rows, "total": total})
+}
+
+func (a *Admin) handleAPIGet(rw http.ResponseWriter, req *http.Request) {
+	m, ok := a.models[mux.Vars(req)["slug"]]
+	if !ok {
+		writeJSONError(rw, http.StatusNotFound, "unknown model")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	row, err := a.Get(m, id)
+	if err != nil {
+		writeJSONError(rw, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(rw, http.StatusOK, row)
+}
+
+func (a *Admin) handleAPISave(rw http.ResponseWriter, req *http.Request) {
+	m, ok := a.models[mux.Vars(req)["slug"]]
+	if !ok {
+		writeJSONError(rw, http.StatusNotFound, "unknown model")
+		return
+	}
+
+	id := 0
+	if idStr, ok := mux.Vars(req)["id"]; ok {
+		id, _ = strconv.Atoi(idStr)
+	}
+
+	var values map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&values); err != nil {
+		writeJSONError(rw, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	savedID, errs := a.Save(m, values, id)
+	if errs != nil {
+		writeJSON(rw, http.StatusUnprocessableEntity, map[string]interface{}{"errors": errs})
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"id": savedID})
+}
+
+func (a *Admin) handleAPIDelete(rw http.ResponseWriter, req *http.Request) {
+	m, ok := a.models[mux.Vars(req)["slug"]]
+	if !ok {
+		writeJSONError(rw, http.StatusNotFound, "unknown model")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if err := a.deleteRows(m, []int{id}); err != nil {
+		writeJSONError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"deleted": id})
+}
@@ -0,0 +1,513 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User is an admin account. Passwords are always stored as bcrypt hashes;
+// use HashPassword to turn a plaintext password into one before assigning
+// it to PasswordHash.
+type User struct {
+	ID           int
+	Username     string
+	PasswordHash string
+	Role         string
+}
+
+// Permission grants a role view/add/change/delete rights on a single model,
+// identified by its slug. A role with no matching Permission is denied
+// everything once Admin.Permissions is non-empty.
+type Permission struct {
+	Role      string
+	ModelSlug string
+	View      bool
+	Add       bool
+	Change    bool
+	Delete    bool
+}
+
+// UserStore persists admin user accounts (bcrypt password hashes and
+// roles), so accounts and password changes survive a restart of the
+// process. Admin uses a legacyUserStore backed by Admin.Users by default;
+// SQLUserStore is provided for deployments that want accounts in the
+// database rather than in config.
+type UserStore interface {
+	// Find returns the user with the given username, or (nil, nil) if
+	// there isn't one.
+	Find(username string) (*User, error)
+
+	// Save inserts user if it has no ID, or updates the existing row
+	// otherwise, setting user.ID on insert.
+	Save(user *User) error
+}
+
+// legacyUserStore adapts the pre-existing Admin.Users config slice to the
+// UserStore interface, so admins that don't configure a store keep working
+// exactly as before (Save only updates the in-memory slice, so password
+// changes are lost on restart). Save matches users by Username rather than
+// ID and never assigns an ID to an inserted user.
+type legacyUserStore struct {
+	admin *Admin
+}
+
+func (l *legacyUserStore) Find(username string) (*User, error) {
+	for _, u := range l.admin.Users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *legacyUserStore) Save(user *User) error {
+	for _, u := range l.admin.Users {
+		if u.Username == user.Username {
+			u.PasswordHash = user.PasswordHash
+			u.Role = user.Role
+			return nil
+		}
+	}
+	l.admin.Users = append(l.admin.Users, user)
+	return nil
+}
+
+// SQLUserStore persists admin user accounts in an "admin_users" table in
+// the admin's own database.
+type SQLUserStore struct {
+	admin *Admin
+}
+
+// NewSQLUserStore returns a UserStore backed by admin.db. Callers must
+// create the admin_users table (id, username, password_hash, role)
+// beforehand.
+func NewSQLUserStore(admin *Admin) *SQLUserStore {
+	return &SQLUserStore{admin: admin}
+}
+
+func (s *SQLUserStore) Find(username string) (*User, error) {
+	row := s.admin.db.QueryRow(
+		"SELECT id, username, password_hash, role FROM admin_users WHERE username = "+s.admin.dialect.Placeholder(1),
+		username,
+	)
+	u := &User{}
+	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *SQLUserStore) Save(user *User) error {
+	dia := s.admin.dialect
+
+	if user.ID == 0 {
+		q := fmt.Sprintf(
+			"INSERT INTO admin_users(username, password_hash, role) VALUES(%v, %v, %v)%v",
+			dia.Placeholder(1), dia.Placeholder(2), dia.Placeholder(3), dia.InsertReturningSuffix(),
+		)
+		if suffix := dia.InsertReturningSuffix(); suffix != "" {
+			return s.admin.db.QueryRow(q, user.Username, user.PasswordHash, user.Role).Scan(&user.ID)
+		}
+		res, err := s.admin.db.Exec(q, user.Username, user.PasswordHash, user.Role)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		user.ID = int(id)
+		return nil
+	}
+
+	q := fmt.Sprintf(
+		"UPDATE admin_users SET password_hash = %v, role = %v WHERE id = %v",
+		dia.Placeholder(1), dia.Placeholder(2), dia.Placeholder(3),
+	)
+	_, err := s.admin.db.Exec(q, user.PasswordHash, user.Role, user.ID)
+	return err
+}
+
+// userStore returns the configured UserStore, falling back to an in-memory
+// one backed by admin.Users for backwards compatibility.
+func (a *Admin) userStore() UserStore {
+	if a.UserStore != nil {
+		return a.UserStore
+	}
+	return &legacyUserStore{a}
+}
+
+// HashPassword bcrypt-hashes a plaintext password for storage in User.PasswordHash.
+func HashPassword(plaintext string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+type session struct {
+	Token     string
+	Username  string
+	Role      string
+	CSRFToken string
+	messages  []flashMessage
+}
+
+type flashMessage struct {
+	Kind string
+	Text string
+}
+
+func (s *session) getMessages() []flashMessage {
+	msgs := s.messages
+	s.messages = nil
+	return msgs
+}
+
+func (s *session) addMessage(kind, text string) {
+	s.messages = append(s.messages, flashMessage{Kind: kind, Text: text})
+}
+
+// SessionStore persists sessions so logged in users survive a restart of the
+// process. Without a configured Admin.SessionStore, Admin keeps sessions in
+// memory in Admin.sessions (see legacyMemoryStore); MemoryStore is the
+// equivalent for explicit use, and FileStore and SQLStore are provided for
+// deployments that need sessions to outlive the process.
+type SessionStore interface {
+	Get(token string) (*session, error)
+	Save(token string, sess *session) error
+	Delete(token string) error
+}
+
+// MemoryStore keeps sessions in memory only. Sessions are lost on restart.
+type MemoryStore struct {
+	sessions map[string]*session
+}
+
+// NewMemoryStore returns a ready to use in-memory SessionStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]*session{}}
+}
+
+func (m *MemoryStore) Get(token string) (*session, error) {
+	return m.sessions[token], nil
+}
+
+func (m *MemoryStore) Save(token string, sess *session) error {
+	m.sessions[token] = sess
+	return nil
+}
+
+func (m *MemoryStore) Delete(token string) error {
+	delete(m.sessions, token)
+	return nil
+}
+
+// FileStore persists sessions as JSON in a single file, so they survive a
+// restart without needing a database.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore returns a SessionStore backed by the file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (f *FileStore) readAll() (map[string]*session, error) {
+	sessions := map[string]*session{}
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (f *FileStore) writeAll(sessions map[string]*session) error {
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.Path, data, 0600)
+}
+
+func (f *FileStore) Get(token string) (*session, error) {
+	sessions, err := f.readAll()
+	if err != nil {
+		return nil, err
+	}
+	return sessions[token], nil
+}
+
+func (f *FileStore) Save(token string, sess *session) error {
+	sessions, err := f.readAll()
+	if err != nil {
+		return err
+	}
+	sessions[token] = sess
+	return f.writeAll(sessions)
+}
+
+func (f *FileStore) Delete(token string) error {
+	sessions, err := f.readAll()
+	if err != nil {
+		return err
+	}
+	delete(sessions, token)
+	return f.writeAll(sessions)
+}
+
+// SQLStore persists sessions in an "admin_sessions" table in the admin's own
+// database, so sessions survive a restart without a separate file or cache.
+type SQLStore struct {
+	admin *Admin
+}
+
+// NewSQLStore returns a SessionStore backed by admin.db. Callers must create
+// the admin_sessions table (token, username, role, csrf_token) beforehand.
+func NewSQLStore(admin *Admin) *SQLStore {
+	return &SQLStore{admin: admin}
+}
+
+func (s *SQLStore) Get(token string) (*session, error) {
+	row := s.admin.db.QueryRow("SELECT username, role, csrf_token FROM admin_sessions WHERE token = "+s.admin.dialect.Placeholder(1), token)
+	sess := &session{Token: token}
+	if err := row.Scan(&sess.Username, &sess.Role, &sess.CSRFToken); err != nil {
+		return nil, nil
+	}
+	return sess, nil
+}
+
+func (s *SQLStore) Save(token string, sess *session) error {
+	dia := s.admin.dialect
+	_, err := s.admin.db.Exec(
+		fmt.Sprintf("DELETE FROM admin_sessions WHERE token = %v", dia.Placeholder(1)),
+		token,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.admin.db.Exec(
+		fmt.Sprintf("INSERT INTO admin_sessions(token, username, role, csrf_token) VALUES(%v, %v, %v, %v)",
+			dia.Placeholder(1), dia.Placeholder(2), dia.Placeholder(3), dia.Placeholder(4)),
+		token, sess.Username, sess.Role, sess.CSRFToken,
+	)
+	return err
+}
+
+func (s *SQLStore) Delete(token string) error {
+	_, err := s.admin.db.Exec(fmt.Sprintf("DELETE FROM admin_sessions WHERE token = %v", s.admin.dialect.Placeholder(1)), token)
+	return err
+}
+
+// sessionStore returns the configured SessionStore, falling back to an
+// in-memory one backed by admin.sessions for backwards compatibility.
+func (a *Admin) sessionStore() SessionStore {
+	if a.SessionStore != nil {
+		return a.SessionStore
+	}
+	return &legacyMemoryStore{a}
+}
+
+// legacyMemoryStore adapts the pre-existing Admin.sessions map to the
+// SessionStore interface, so admins that don't configure a store keep
+// working exactly as before.
+type legacyMemoryStore struct {
+	admin *Admin
+}
+
+func (l *legacyMemoryStore) Get(token string) (*session, error) {
+	return l.admin.sessions[token], nil
+}
+
+func (l *legacyMemoryStore) Save(token string, sess *session) error {
+	l.admin.sessions[token] = sess
+	return nil
+}
+
+func (l *legacyMemoryStore) Delete(token string) error {
+	delete(l.admin.sessions, token)
+	return nil
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getUserSession returns the session for the "admin" cookie on req, or nil
+// if the request isn't authenticated.
+func (a *Admin) getUserSession(req *http.Request) *session {
+	cookie, err := req.Cookie("admin")
+	if err != nil {
+		return nil
+	}
+
+	sess, err := a.sessionStore().Get(cookie.Value)
+	if err != nil || sess == nil {
+		return nil
+	}
+	return sess
+}
+
+// logIn validates username/password against the configured UserStore
+// (Admin.Users by default) or the legacy single Username/Password pair, and
+// on success starts a session and sets the "admin" cookie.
+func (a *Admin) logIn(rw http.ResponseWriter, username, password string) bool {
+	role := ""
+	ok := false
+
+	if user, err := a.userStore().Find(username); err == nil && user != nil {
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil {
+			ok = true
+			role = user.Role
+		}
+	}
+
+	if !ok && username == a.Username && password == a.Password {
+		ok = true
+		role = "admin"
+	}
+
+	if !ok {
+		return false
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return false
+	}
+	csrfToken, err := generateToken()
+	if err != nil {
+		return false
+	}
+
+	sess := &session{Token: token, Username: username, Role: role, CSRFToken: csrfToken}
+	if err := a.sessionStore().Save(token, sess); err != nil {
+		return false
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     "admin",
+		Value:    token,
+		Path:     a.Path,
+		HttpOnly: true,
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+	})
+	return true
+}
+
+// logOut removes the session behind the "admin" cookie on req.
+func (a *Admin) logOut(req *http.Request) {
+	cookie, err := req.Cookie("admin")
+	if err != nil {
+		return
+	}
+	a.sessionStore().Delete(cookie.Value)
+}
+
+// validCSRFToken reports whether token matches the CSRF token bound to sess.
+func validCSRFToken(sess *session, token string) bool {
+	if sess == nil || sess.CSRFToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(sess.CSRFToken), []byte(token)) == 1
+}
+
+// csrfField renders the hidden input forms must include for their POST to
+// pass the handlerWrapper CSRF check.
+func csrfField(sess *session) string {
+	if sess == nil {
+		return ""
+	}
+	return fmt.Sprintf(`<input type="hidden" name="_csrf" value="%v">`, sess.CSRFToken)
+}
+
+// hasPermission reports whether role may perform action ("view", "add",
+// "change" or "delete") on the model identified by slug. When Admin.Permissions
+// is empty, every logged in user may do everything, matching the single
+// admin behaviour the package had before roles existed.
+func (a *Admin) hasPermission(role, slug, action string) bool {
+	if len(a.Permissions) == 0 {
+		return true
+	}
+
+	for _, perm := range a.Permissions {
+		if perm.Role != role || perm.ModelSlug != slug {
+			continue
+		}
+		switch action {
+		case "view":
+			return perm.View
+		case "add":
+			return perm.Add
+		case "change":
+			return perm.Change
+		case "delete":
+			return perm.Delete
+		}
+	}
+	return false
+}
+
+func (a *Admin) handleChangePassword(rw http.ResponseWriter, req *http.Request) {
+	sess := a.getUserSession(req)
+
+	if req.Method == "POST" {
+		req.ParseForm()
+		current := req.Form.Get("current_password")
+		newPassword := req.Form.Get("new_password")
+
+		changed := false
+		if user, err := a.userStore().Find(sess.Username); err == nil && user != nil {
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) == nil {
+				if hash, err := HashPassword(newPassword); err == nil {
+					user.PasswordHash = hash
+					if err := a.userStore().Save(user); err == nil {
+						changed = true
+					}
+				}
+			}
+		} else if sess.Username == a.Username && current == a.Password {
+			// Legacy single-admin account: there's no user row to persist
+			// to, so the new password just replaces Admin.Password in
+			// memory, same as before a UserStore existed.
+			a.Password = newPassword
+			changed = true
+		}
+
+		if changed {
+			sess.addMessage("success", "Password has been changed.")
+			http.Redirect(rw, req, a.Path, 302)
+			return
+		}
+		sess.addMessage("error", "Current password is incorrect.")
+	}
+
+	a.render(rw, req, "password.html", map[string]interface{}{
+		"csrf": template.HTML(csrfField(sess)),
+	})
+}
@@ -0,0 +1,111 @@
+package admin
+
+import "fmt"
+
+// Dialect abstracts the SQL differences between the database drivers the
+// admin can run against, so query-building code doesn't need to special
+// case each one.
+type Dialect interface {
+	// Name returns the dialect's driver name, e.g. "postgres".
+	Name() string
+
+	// Placeholder returns the parameter marker for the nth (1-indexed)
+	// bind variable in a query.
+	Placeholder(n int) string
+
+	// Quote returns an identifier quoted the way this dialect expects.
+	Quote(identifier string) string
+
+	// LimitOffset returns the SQL fragment used to page a SELECT.
+	LimitOffset(limit, offset int) string
+
+	// InsertReturningSuffix returns the SQL fragment (if any) an INSERT
+	// needs appended to report back the new row's id in the result set,
+	// e.g. " RETURNING id" for Postgres. Dialects whose driver supports
+	// sql.Result.LastInsertId (SQLite, MySQL) return "".
+	InsertReturningSuffix() string
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) Quote(identifier string) string {
+	return fmt.Sprintf("%q", identifier)
+}
+
+func (sqliteDialect) LimitOffset(limit, offset int) string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (sqliteDialect) InsertReturningSuffix() string {
+	return ""
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) Quote(identifier string) string {
+	return fmt.Sprintf("`%v`", identifier)
+}
+
+func (mysqlDialect) LimitOffset(limit, offset int) string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (mysqlDialect) InsertReturningSuffix() string {
+	return ""
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgresDialect) Quote(identifier string) string {
+	return fmt.Sprintf("%q", identifier)
+}
+
+func (postgresDialect) LimitOffset(limit, offset int) string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (postgresDialect) InsertReturningSuffix() string {
+	// lib/pq doesn't implement sql.Result.LastInsertId; RETURNING is the
+	// idiomatic way to get the new row's id back on a single round trip.
+	return " RETURNING id"
+}
+
+// dialectFor resolves a Dialect from a driver name. Callers are expected to
+// have blank-imported the matching database/sql driver package themselves,
+// the same way they would when using database/sql directly.
+func dialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "", "sqlite3":
+		return sqliteDialect{}, nil
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "postgres":
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("admin: unsupported database driver %q", driver)
+	}
+}
@@ -0,0 +1,327 @@
+package admin
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"time"
+)
+
+// fieldAttrs holds the configuration every Field shares, regardless of the
+// widget it renders. Field implementations embed *BaseField to get it, and
+// expose it through Attrs() so main.go can read/write it generically.
+type fieldAttrs struct {
+	name         string
+	columnName   string
+	label        string
+	list         bool
+	searchable   bool
+	listFilter   bool
+	defaultValue string
+	width        int
+}
+
+// Field is implemented by every admin form field. A model's struct fields
+// are mapped to Fields by kind in modelGroup.RegisterModel, or overridden
+// with the `admin:"field=..."` tag via customFields.
+type Field interface {
+	Attrs() *fieldAttrs
+	Configure(options map[string]string) error
+	Render(w io.Writer, val interface{}, err string, startRow bool)
+	RenderString(val interface{}) template.HTML
+	Validate(raw string) (interface{}, error)
+}
+
+// FilterableField is implemented by fields that can render a column filter
+// control (a `?filter_<col>=` widget) on the list view. Fields that don't
+// implement it simply don't show up as filterable.
+type FilterableField interface {
+	FilterWidget(colName, currentVal string) template.HTML
+}
+
+// FilterClauseBuilder is implemented by fields whose filter value isn't a
+// plain "col = value" match, e.g. TimeField's from/to range or
+// BooleanField's "true"/"false" needing to become what the column actually
+// stores. nextPlaceholder binds value as the query's next argument and
+// returns the placeholder to use for it in the returned clause. Returning ""
+// means the filter matched nothing usable and should be skipped.
+type FilterClauseBuilder interface {
+	FilterClause(col, raw string, nextPlaceholder func(value interface{}) string) string
+}
+
+// FileHandlerField is implemented by fields that accept a multipart file
+// upload instead of (or in addition to) a plain form value.
+type FileHandlerField interface {
+	HandleFile(file *multipart.FileHeader) (string, error)
+}
+
+// BaseField carries the shared fieldAttrs and default Attrs()/Configure()
+// behaviour. Concrete fields embed *BaseField and only implement the
+// widget-specific methods.
+type BaseField struct {
+	attrs fieldAttrs
+}
+
+func (b *BaseField) Attrs() *fieldAttrs {
+	return &b.attrs
+}
+
+// Configure applies no field-specific options. Fields that understand extra
+// tag options (e.g. `admin:"accept=image/*"`) override this.
+func (b *BaseField) Configure(options map[string]string) error {
+	return nil
+}
+
+// customFields lets callers register a Field implementation under a name,
+// used with the `admin:"field=name"` struct tag to override the default
+// field chosen for a struct field's Go kind.
+var customFields = map[string]Field{}
+
+// RegisterField makes a custom Field implementation available under name
+// for use with `admin:"field=name"`.
+func RegisterField(name string, field Field) {
+	customFields[name] = field
+}
+
+// TextField renders a plain text input and stores its value as a string.
+type TextField struct {
+	*BaseField
+}
+
+func (f *TextField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "text.html", val, errStr, startRow)
+}
+
+func (f *TextField) RenderString(val interface{}) template.HTML {
+	return template.HTML(template.HTMLEscapeString(fmt.Sprintf("%v", val)))
+}
+
+func (f *TextField) Validate(raw string) (interface{}, error) {
+	return raw, nil
+}
+
+// IntField renders a number input and stores its value as an int.
+type IntField struct {
+	*BaseField
+}
+
+func (f *IntField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "int.html", val, errStr, startRow)
+}
+
+func (f *IntField) RenderString(val interface{}) template.HTML {
+	return template.HTML(fmt.Sprintf("%v", val))
+}
+
+func (f *IntField) Validate(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+// FloatField renders a number input and stores its value as a float64.
+type FloatField struct {
+	*BaseField
+}
+
+func (f *FloatField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "float.html", val, errStr, startRow)
+}
+
+func (f *FloatField) RenderString(val interface{}) template.HTML {
+	return template.HTML(fmt.Sprintf("%v", val))
+}
+
+func (f *FloatField) Validate(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// BooleanField renders a checkbox and stores its value as a bool.
+type BooleanField struct {
+	*BaseField
+}
+
+func (f *BooleanField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "boolean.html", val, errStr, startRow)
+}
+
+func (f *BooleanField) RenderString(val interface{}) template.HTML {
+	if val == true {
+		return template.HTML("&#10003;")
+	}
+	return template.HTML("&#10007;")
+}
+
+func (f *BooleanField) Validate(raw string) (interface{}, error) {
+	return raw == "true" || raw == "on" || raw == "1", nil
+}
+
+// FilterWidget renders a tri-state (any/yes/no) select for ?filter_<col>=.
+func (f *BooleanField) FilterWidget(colName, currentVal string) template.HTML {
+	options := []struct{ Value, Label string }{
+		{"", "Any"},
+		{"true", "Yes"},
+		{"false", "No"},
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<select name="filter_%v">`, colName)
+	for _, opt := range options {
+		selected := ""
+		if opt.Value == currentVal {
+			selected = " selected"
+		}
+		fmt.Fprintf(&buf, `<option value="%v"%v>%v</option>`, opt.Value, selected, opt.Label)
+	}
+	buf.WriteString("</select>")
+	return template.HTML(buf.String())
+}
+
+// FilterClause normalizes the "true"/"false" FilterWidget submits to an
+// actual bool, so it's compared against whatever the driver stores for this
+// column rather than being bound as a string that can never match.
+func (f *BooleanField) FilterClause(col, raw string, nextPlaceholder func(value interface{}) string) string {
+	return fmt.Sprintf("%v = %v", col, nextPlaceholder(raw == "true"))
+}
+
+// TimeField renders a datetime input and stores its value as a time.Time.
+type TimeField struct {
+	*BaseField
+}
+
+func (f *TimeField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "time.html", val, errStr, startRow)
+}
+
+func (f *TimeField) RenderString(val interface{}) template.HTML {
+	t, ok := val.(time.Time)
+	if !ok {
+		return ""
+	}
+	return template.HTML(t.Format("2006-01-02 15:04"))
+}
+
+func (f *TimeField) Validate(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	return time.Parse("2006-01-02T15:04", raw)
+}
+
+// FilterWidget renders a from/to date-range picker for ?filter_<col>=.
+func (f *TimeField) FilterWidget(colName, currentVal string) template.HTML {
+	from, to := splitRange(currentVal)
+	return template.HTML(fmt.Sprintf(
+		`<input type="date" name="filter_%v_from" value="%v"> to <input type="date" name="filter_%v_to" value="%v">`,
+		colName, from, colName, to,
+	))
+}
+
+// FilterClause turns the "<from>|<to>" value FilterWidget submits into a
+// BETWEEN, or a one-sided >=/<= if only one bound was given. An unparseable
+// or empty range is skipped rather than filtering out every row.
+func (f *TimeField) FilterClause(col, raw string, nextPlaceholder func(value interface{}) string) string {
+	from, to := splitRange(raw)
+	fromTime, fromErr := time.Parse("2006-01-02", from)
+	toTime, toErr := time.Parse("2006-01-02", to)
+
+	switch {
+	case fromErr == nil && toErr == nil:
+		return fmt.Sprintf("%v BETWEEN %v AND %v", col, nextPlaceholder(fromTime), nextPlaceholder(to24(toTime)))
+	case fromErr == nil:
+		return fmt.Sprintf("%v >= %v", col, nextPlaceholder(fromTime))
+	case toErr == nil:
+		return fmt.Sprintf("%v <= %v", col, nextPlaceholder(to24(toTime)))
+	default:
+		return ""
+	}
+}
+
+// to24 shifts a date to the last instant of that day, so a "to" bound
+// entered as a bare date includes the whole day rather than just midnight.
+func to24(t time.Time) time.Time {
+	return t.Add(24*time.Hour - time.Nanosecond)
+}
+
+func splitRange(val string) (from, to string) {
+	for i := 0; i < len(val); i++ {
+		if val[i] == '|' {
+			return val[:i], val[i+1:]
+		}
+	}
+	return val, ""
+}
+
+// ForeignKeyField renders a dropdown of another model's rows and stores its
+// value as that row's id.
+type ForeignKeyField struct {
+	*BaseField
+	model *model
+	db    *sql.DB
+}
+
+func (f *ForeignKeyField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "foreignkey.html", val, errStr, startRow)
+}
+
+func (f *ForeignKeyField) RenderString(val interface{}) template.HTML {
+	return template.HTML(fmt.Sprintf("%v", val))
+}
+
+func (f *ForeignKeyField) Validate(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+// FilterWidget renders a dropdown of up to 200 related rows for
+// ?filter_<col>=.
+func (f *ForeignKeyField) FilterWidget(colName, currentVal string) template.HTML {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<select name="filter_%v"><option value="">Any</option>`, colName)
+
+	if f.db != nil && f.model != nil {
+		rows, err := f.db.Query(fmt.Sprintf("SELECT id FROM %v LIMIT 200", f.model.tableName))
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var id int
+				if rows.Scan(&id) != nil {
+					continue
+				}
+				selected := ""
+				if fmt.Sprintf("%v", id) == currentVal {
+					selected = " selected"
+				}
+				fmt.Fprintf(&buf, `<option value="%v"%v>%v #%v</option>`, id, selected, f.model.Name, id)
+			}
+		}
+	}
+
+	buf.WriteString("</select>")
+	return template.HTML(buf.String())
+}
+
+func renderFieldTemplate(w io.Writer, attrs *fieldAttrs, tmplName string, val interface{}, errStr string, startRow bool) {
+	ctx := map[string]interface{}{
+		"name":     attrs.columnName,
+		"label":    attrs.label,
+		"width":    attrs.width,
+		"val":      val,
+		"error":    errStr,
+		"startrow": startRow,
+		"tmpl":     tmplName,
+	}
+	if err := templates.ExecuteTemplate(w, "FieldWrapper", ctx); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
-	"strings"
 )
 
 var templates *template.Template
@@ -31,13 +30,26 @@ func (a *Admin) render(rw http.ResponseWriter, req *http.Request, tmpl string, c
 	}
 }
 
-// handlerWrapper is used to redirect to index / log in page.
+// handlerWrapper is used to redirect to index / log in page, and to enforce
+// the synchronizer-token CSRF check on authenticated POST requests.
 func (a *Admin) handlerWrapper(h http.HandlerFunc) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		if a.getUserSession(req) == nil && req.URL.Path != a.Path+"/" {
+		sess := a.getUserSession(req)
+		if sess == nil && req.URL.Path != a.Path+"/" {
 			http.Redirect(rw, req, a.Path, 302)
 			return
 		}
+
+		if sess != nil && req.Method == "POST" {
+			if err := req.ParseMultipartForm(1024 * 1000); err != nil {
+				req.ParseForm()
+			}
+			if !validCSRFToken(sess, req.Form.Get("_csrf")) {
+				http.Error(rw, "CSRF token invalid or missing", http.StatusForbidden)
+				return
+			}
+		}
+
 		h.ServeHTTP(rw, req)
 	}
 }
@@ -62,12 +74,7 @@ func (a *Admin) handleIndex(rw http.ResponseWriter, req *http.Request) {
 }
 
 func (a *Admin) handleLogout(rw http.ResponseWriter, req *http.Request) {
-	cookie, err := req.Cookie("admin")
-	if err != nil {
-		return
-	}
-
-	delete(a.sessions, cookie.Value)
+	a.logOut(req)
 	http.Redirect(rw, req, a.Path, 302)
 }
 func (a *Admin) handleList(rw http.ResponseWriter, req *http.Request) {
@@ -80,18 +87,53 @@ func (a *Admin) handleList(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	sess := a.getUserSession(req)
+	if !a.hasPermission(sess.Role, slug, "view") {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	req.ParseForm()
-	q := req.Form.Get("q")
+	opts := listOptions{
+		q:       req.Form.Get("q"),
+		sortCol: req.Form.Get("sort"),
+		order:   req.Form.Get("order"),
+		filters: map[string]string{},
+	}
+	page, _ := strconv.Atoi(req.Form.Get("page"))
+	opts.page = page
+	perPage, _ := strconv.Atoi(req.Form.Get("per_page"))
+	opts.perPage = perPage
+
+	fields := model.listFields()
+	filterChips := map[string]string{}
+	for _, field := range fields {
+		col := field.Attrs().columnName
+		val := req.Form.Get("filter_" + col)
+
+		// Range filters (e.g. TimeField's date picker) submit "_from"/"_to"
+		// instead of a single value; combine them into FilterWidget's
+		// "<from>|<to>" convention.
+		from := req.Form.Get("filter_" + col + "_from")
+		to := req.Form.Get("filter_" + col + "_to")
+		if from != "" || to != "" {
+			val = from + "|" + to
+		}
+
+		if val == "" {
+			continue
+		}
+		opts.filters[col] = val
+		filterChips[col] = val
+	}
 
-	results, err := a.queryModel(model, q)
+	results, total, err := a.queryModelFiltered(model, opts)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	fmt.Println(results)
 
 	strResults := [][]template.HTML{}
-	fields := model.listFields
 	for _, row := range results {
 		s := make([]template.HTML, len(row))
 		for i, val := range row {
@@ -100,6 +142,19 @@ func (a *Admin) handleList(rw http.ResponseWriter, req *http.Request) {
 		strResults = append(strResults, s)
 	}
 
+	filterWidgets := map[string]template.HTML{}
+	for _, field := range fields {
+		if !field.Attrs().listFilter {
+			continue
+		}
+		filterable, ok := field.(FilterableField)
+		if !ok {
+			continue
+		}
+		col := field.Attrs().columnName
+		filterWidgets[col] = filterable.FilterWidget(col, opts.filters[col])
+	}
+
 	var tmpl string
 	if view, ok := vars["view"]; ok && view == "popup" {
 		tmpl = "popup.html"
@@ -107,27 +162,30 @@ func (a *Admin) handleList(rw http.ResponseWriter, req *http.Request) {
 		tmpl = "list.html"
 	}
 
+	effectivePerPage := opts.perPage
+	if effectivePerPage < 1 {
+		effectivePerPage = a.PerPage
+	}
+
 	a.render(rw, req, tmpl, map[string]interface{}{
-		"name":    model.Name,
-		"slug":    slug,
-		"columns": model.listColumns,
-		"results": strResults,
-		"skipId":  model.listTableColumns[0] != "id",
+		"name":          model.Name,
+		"slug":          slug,
+		"columns":       model.listColumns(),
+		"results":       strResults,
+		"skipId":        model.listTableColumns()[0] != "id",
+		"actions":       model.actionNames(),
+		"csrf":          template.HTML(csrfField(sess)),
+		"page":          opts.page,
+		"totalPages":    totalPages(total, effectivePerPage),
+		"totalRows":     total,
+		"sort":          opts.sortCol,
+		"order":         opts.order,
+		"filters":       filterChips,
+		"filterWidgets": filterWidgets,
 	})
 }
 
 func (a *Admin) handleEdit(rw http.ResponseWriter, req *http.Request) {
-	// Set up data and error slices. If we're POSTing, they'll be nil
-	// if no errors were found during validation.
-	var data map[string]interface{}
-	var errors map[string]string
-	if req.Method == "POST" {
-		data, errors = a.handleSave(rw, req)
-		if data == nil {
-			return
-		}
-	}
-
 	// The model we're editing
 	vars := mux.Vars(req)
 	slug := vars["slug"]
@@ -149,6 +207,27 @@ func (a *Admin) handleEdit(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	sess := a.getUserSession(req)
+	action := "add"
+	if id != 0 {
+		action = "change"
+	}
+	if !a.hasPermission(sess.Role, slug, action) {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Set up data and error slices. If we're POSTing, they'll be nil
+	// if no errors were found during validation.
+	var data map[string]interface{}
+	var errors map[string]string
+	if req.Method == "POST" {
+		data, errors = a.handleSave(rw, req)
+		if data == nil {
+			return
+		}
+	}
+
 	// If no errors / not yet submitted for validation, and we're editing, get data from db
 	if errors == nil && id != 0 {
 		var err error
@@ -161,6 +240,7 @@ func (a *Admin) handleEdit(rw http.ResponseWriter, req *http.Request) {
 
 	// Render form and template
 	var buf bytes.Buffer
+	buf.WriteString(csrfField(sess))
 	model.renderForm(&buf, data, id == 0, errors)
 
 	a.render(rw, req, "edit.html", map[string]interface{}{
@@ -176,8 +256,6 @@ func (a *Admin) handleSave(rw http.ResponseWriter, req *http.Request) (map[strin
 	if err != nil {
 		return nil, nil
 	}
-	fmt.Println(req.MultipartForm.Value)
-	fmt.Println(req.MultipartForm.File)
 
 	vars := mux.Vars(req)
 	slug := vars["slug"]
@@ -195,14 +273,24 @@ func (a *Admin) handleSave(rw http.ResponseWriter, req *http.Request) (map[strin
 		}
 	}
 
-	numFields := len(model.fieldNames) - 1 // No need for ID.
+	sess := a.getUserSession(req)
+	action := "add"
+	if id != 0 {
+		action = "change"
+	}
+	if !a.hasPermission(sess.Role, slug, action) {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return nil, nil
+	}
+
+	numFields := len(model.fieldNames()) - 1 // No need for ID.
 
 	// Get data from POST and fill a slice
 	data := map[string]interface{}{}
 	errors := map[string]string{}
 	hasErrors := false
 	for i := 0; i < numFields; i++ {
-		fieldName := model.fieldNames[i+1]
+		fieldName := model.fieldNames()[i+1]
 		field := model.fieldByName(fieldName)
 		rawValue := req.Form.Get(fieldName)
 
@@ -212,7 +300,9 @@ func (a *Admin) handleSave(rw http.ResponseWriter, req *http.Request) (map[strin
 			if ok {
 				filename, err := fileField.HandleFile(files[0])
 				if err != nil {
-					panic(err)
+					errors[fieldName] = err.Error()
+					hasErrors = true
+					continue
 				}
 				rawValue = filename
 			}
@@ -235,43 +325,15 @@ func (a *Admin) handleSave(rw http.ResponseWriter, req *http.Request) (map[strin
 		return data, errors
 	}
 
-	// Create query
-	changedCols := make([]string, len(data))
-	changedData := make([]interface{}, len(data))
-	i := 0
-	for key, value := range data {
-		col := key
-		if a.NameTransform != nil {
-			col = a.NameTransform(key)
-		}
-		if id != 0 {
-			col = fmt.Sprintf("%v = ?", col)
-		}
-		changedCols[i] = col
-		changedData[i] = value
-		i++
-	}
-
-	valMarks := strings.Repeat("?, ", len(data))
-	valMarks = valMarks[0 : len(valMarks)-2]
-
-	var q string
-	if id != 0 {
-		q = fmt.Sprintf("UPDATE %v SET %v WHERE id = %v", model.tableName, strings.Join(changedCols, ", "), id)
-	} else {
-		q = fmt.Sprintf("INSERT INTO %v(%v) VALUES(%v)", model.tableName, strings.Join(changedCols, ", "), valMarks)
+	if crossErrors := model.validate(data); len(crossErrors) > 0 {
+		return data, crossErrors
 	}
 
-	fmt.Println(q)
-
-	sess := a.getUserSession(req)
-
-	fmt.Println(changedData)
-	_, err = a.db.Exec(q, changedData...)
+	savedID, err := a.saveRow(model, data, id)
 	if err != nil {
-		fmt.Println(err)
-		return nil, nil
+		return data, map[string]string{"_": err.Error()}
 	}
+	id = savedID
 
 	sess.addMessage("success", fmt.Sprintf("%v has been saved.", model.Name))
 
@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listOptions holds the parsed ?page=, ?per_page=, ?sort=, ?order= and
+// ?filter_<col>= query params handleList understands.
+type listOptions struct {
+	q       string
+	sortCol string
+	order   string
+	filters map[string]string
+	page    int
+	perPage int
+}
+
+// queryModelFiltered runs a search+filter+sort+paginated SELECT against
+// m's table, returning the matching rows for the requested page alongside
+// the total number of matching rows (ignoring pagination).
+func (a *Admin) queryModelFiltered(m *model, opts listOptions) ([][]interface{}, int, error) {
+	cols := m.listTableColumns()
+	where := []string{}
+	args := []interface{}{}
+
+	if opts.q != "" {
+		ors := []string{}
+		for _, col := range m.searchableColumns() {
+			args = append(args, "%"+opts.q+"%")
+			ors = append(ors, fmt.Sprintf("%v LIKE %v", col, a.dialect.Placeholder(len(args))))
+		}
+		if len(ors) > 0 {
+			where = append(where, "("+strings.Join(ors, " OR ")+")")
+		}
+	}
+
+	nextPlaceholder := func(value interface{}) string {
+		args = append(args, value)
+		return a.dialect.Placeholder(len(args))
+	}
+
+	for _, field := range m.fields {
+		col := field.Attrs().columnName
+		val, ok := opts.filters[col]
+		if !ok || val == "" {
+			continue
+		}
+
+		if builder, ok := field.(FilterClauseBuilder); ok {
+			if clause := builder.FilterClause(col, val, nextPlaceholder); clause != "" {
+				where = append(where, clause)
+			}
+			continue
+		}
+
+		where = append(where, fmt.Sprintf("%v = %v", col, nextPlaceholder(val)))
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	countQ := fmt.Sprintf("SELECT COUNT(*) FROM %v %v", m.tableName, whereClause)
+	if err := a.db.QueryRow(countQ, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderClause := ""
+	if opts.sortCol != "" && isTableColumn(m, opts.sortCol) {
+		dir := "ASC"
+		if opts.order == "desc" {
+			dir = "DESC"
+		}
+		orderClause = fmt.Sprintf("ORDER BY %v %v", opts.sortCol, dir)
+	}
+
+	perPage := opts.perPage
+	if perPage < 1 {
+		perPage = a.PerPage
+	}
+	page := opts.page
+	if page < 1 {
+		page = 1
+	}
+
+	q := fmt.Sprintf(
+		"SELECT %v FROM %v %v %v %v",
+		strings.Join(cols, ", "), m.tableName, whereClause, orderClause, a.dialect.LimitOffset(perPage, (page-1)*perPage),
+	)
+
+	rows, err := a.db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := [][]interface{}{}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, vals)
+	}
+
+	return results, total, nil
+}
+
+// querySingleModel fetches one row of m by id as a column name -> value map,
+// using the admin's Dialect for its placeholder so it works the same across
+// SQLite/MySQL/Postgres. It's the read path behind handleEdit and the JSON
+// API's Get.
+func (a *Admin) querySingleModel(m *model, id int) (map[string]interface{}, error) {
+	cols := m.tableColumns()
+	q := fmt.Sprintf("SELECT %v FROM %v WHERE id = %v", strings.Join(cols, ", "), m.tableName, a.dialect.Placeholder(1))
+
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+
+	if err := a.db.QueryRow(q, id).Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	row := map[string]interface{}{}
+	for i, col := range cols {
+		row[col] = vals[i]
+	}
+	return row, nil
+}
+
+// isTableColumn reports whether col is one of m's real table columns. Used
+// to whitelist ?sort= against SQL injection, since it's interpolated
+// straight into an ORDER BY clause rather than bound as a parameter.
+func isTableColumn(m *model, col string) bool {
+	for _, c := range m.tableColumns() {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// totalPages returns how many pages of perPage rows it takes to cover total
+// rows, always at least 1.
+func totalPages(total, perPage int) int {
+	if perPage < 1 {
+		perPage = 1
+	}
+	pages := (total + perPage - 1) / perPage
+	if pages < 1 {
+		pages = 1
+	}
+	return pages
+}
@@ -20,16 +20,48 @@ type Admin struct {
 	Router        *mux.Router
 	Path          string
 	Database      string
+	Driver        string
 	Title         string
 	NameTransform func(string) string
 
+	// PerPage is the number of rows handleList shows per page. Defaults
+	// to 50.
+	PerPage int
+
+	// Username and Password log in a single admin account. Prefer Users
+	// with roles and bcrypt-hashed passwords for anything beyond local
+	// development.
 	Username string
 	Password string
-	sessions map[string]*session
+	Users    []*User
+
+	// UserStore persists Users (and password changes to them) across
+	// restarts. Defaults to an in-memory store backed by Users above.
+	UserStore UserStore
+
+	// Permissions grants roles view/add/change/delete rights per model.
+	// Leaving it empty keeps the old behaviour of letting any logged in
+	// user do everything.
+	Permissions []*Permission
+
+	// SessionStore persists sessions across restarts. Defaults to an
+	// in-memory store backed by sessions below.
+	SessionStore SessionStore
+	sessions     map[string]*session
+
+	// Tokens authenticates the JSON API under {Path}/api/v1/ as an
+	// alternative to the cookie session, so API clients don't need to
+	// scrape the login form.
+	Tokens []*APIToken
+
+	// Storage is consulted by every FileField/ImageField to store
+	// uploads. Defaults to LocalStorage under SourceDir/static/uploads.
+	Storage Storage
 
 	SourceDir string
 
 	db            *sql.DB
+	dialect       Dialect
 	models        map[string]*model
 	modelGroups   []*modelGroup
 	registeredFKs map[reflect.Type]*model
@@ -57,6 +89,17 @@ func Setup(admin *Admin) (*Admin, error) {
 		admin.Title = "Admin"
 	}
 
+	if admin.PerPage == 0 {
+		admin.PerPage = 50
+	}
+
+	if admin.Storage == nil {
+		admin.Storage = NewLocalStorage(
+			fmt.Sprintf("%v/static/uploads", admin.SourceDir),
+			fmt.Sprintf("%v/static/uploads/", admin.Path),
+		)
+	}
+
 	// Users / sessions
 	if len(admin.Username) == 0 || len(admin.Password) == 0 {
 		return nil, errors.New("Username and/or password is missing")
@@ -72,7 +115,16 @@ func Setup(admin *Admin) (*Admin, error) {
 	}
 
 	// Database
-	db, err := sql.Open("sqlite3", admin.Database)
+	// Driver defaults to sqlite3. For other drivers (e.g. "postgres",
+	// "mysql"), the caller must blank-import the matching database/sql
+	// driver package so it registers itself before Setup runs.
+	dialect, err := dialectFor(admin.Driver)
+	if err != nil {
+		return nil, err
+	}
+	admin.dialect = dialect
+
+	db, err := sql.Open(dialect.Name(), admin.Database)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +141,21 @@ func Setup(admin *Admin) (*Admin, error) {
 	sr.StrictSlash(true)
 	sr.HandleFunc("/", admin.handlerWrapper(admin.handleIndex))
 	sr.HandleFunc("/logout/", admin.handlerWrapper(admin.handleLogout))
+	sr.HandleFunc("/password/", admin.handlerWrapper(admin.handleChangePassword))
 	sr.HandleFunc("/model/{slug}/", admin.handlerWrapper(admin.handleList))
+	sr.HandleFunc("/model/{slug}/action/", admin.handlerWrapper(admin.handleAction))
 	sr.HandleFunc("/model/{slug}/new/", admin.handlerWrapper(admin.handleEdit))
 	sr.HandleFunc("/model/{slug}/{view}/", admin.handlerWrapper(admin.handleList))
 	sr.HandleFunc("/model/{slug}/edit/{id}/", admin.handlerWrapper(admin.handleEdit))
 	sr.PathPrefix("/static/").Handler(http.StripPrefix("/admin/static/", http.FileServer(http.Dir(staticDir))))
 
+	// JSON REST API, for decoupled frontends that don't want the HTML admin.
+	sr.HandleFunc("/api/v1/{slug}/", admin.apiWrapper(admin.handleAPIList)).Methods("GET")
+	sr.HandleFunc("/api/v1/{slug}/", admin.apiWriteWrapper(admin.handleAPISave)).Methods("POST")
+	sr.HandleFunc("/api/v1/{slug}/{id}/", admin.apiWrapper(admin.handleAPIGet)).Methods("GET")
+	sr.HandleFunc("/api/v1/{slug}/{id}/", admin.apiWriteWrapper(admin.handleAPISave)).Methods("PUT")
+	sr.HandleFunc("/api/v1/{slug}/{id}/", admin.apiWriteWrapper(admin.handleAPIDelete)).Methods("DELETE")
+
 	return admin, nil
 }
 
@@ -155,6 +216,7 @@ func (g *modelGroup) RegisterModel(mdl interface{}) error {
 		tableName: tableName,
 		fields:    []Field{},
 		instance:  mdl,
+		actions:   map[string]ModelAction{},
 	}
 
 	// Set as registered so it can be used as a ForeignKey from other models
@@ -169,6 +231,7 @@ func (g *modelGroup) RegisterModel(mdl interface{}) error {
 		}
 
 		field.model = &am
+		field.db = g.admin.db
 		delete(g.admin.missingFKs, field)
 	}
 
@@ -234,6 +297,7 @@ func (g *modelGroup) RegisterModel(mdl interface{}) error {
 				// We need the field to know what model it's related to
 				if regModel, ok := g.admin.registeredFKs[fieldType]; ok {
 					field.(*ForeignKeyField).model = regModel
+					field.(*ForeignKeyField).db = g.admin.db
 				} else {
 					g.admin.missingFKs[field.(*ForeignKeyField)] = refl.Type
 				}
@@ -251,6 +315,10 @@ func (g *modelGroup) RegisterModel(mdl interface{}) error {
 			panic(err)
 		}
 
+		if consumer, ok := field.(storageConsumer); ok {
+			consumer.setStorage(g.admin.Storage)
+		}
+
 		field.Attrs().columnName = tableField
 		if label, ok := tagMap["label"]; ok {
 			field.Attrs().label = label
@@ -266,6 +334,10 @@ func (g *modelGroup) RegisterModel(mdl interface{}) error {
 			field.Attrs().searchable = true
 		}
 
+		if _, ok := tagMap["list_filter"]; ok {
+			field.Attrs().listFilter = true
+		}
+
 		if val, ok := tagMap["default"]; ok {
 			field.Attrs().defaultValue = val
 		}
@@ -294,6 +366,7 @@ type model struct {
 	fields    []Field
 	tableName string
 	instance  interface{}
+	actions   map[string]ModelAction
 }
 
 func (m *model) renderForm(w io.Writer, data []interface{}, defaults bool, errors []string) {
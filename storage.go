@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Storage abstracts where uploaded files are written, so file and image
+// fields don't need to care whether they end up on local disk or in object
+// storage. Admin.Storage is consulted by every FileField/ImageField.
+type Storage interface {
+	// Put writes the contents of r under name and returns the URL it can
+	// be served from.
+	Put(name string, r io.Reader) (url string, err error)
+
+	// Delete removes name, if it exists.
+	Delete(name string) error
+
+	// URL returns the URL name would be served from, without touching
+	// storage.
+	URL(name string) string
+}
+
+// LocalStorage stores uploads on local disk, under Dir, and serves them
+// from BaseURL.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocalStorage returns a Storage that writes uploads to dir and serves
+// them from baseURL (e.g. "/admin/static/uploads/").
+func NewLocalStorage(dir, baseURL string) *LocalStorage {
+	return &LocalStorage{Dir: dir, BaseURL: baseURL}
+}
+
+func (s *LocalStorage) Put(name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(s.Dir, name), data, 0644); err != nil {
+		return "", err
+	}
+
+	return s.URL(name), nil
+}
+
+func (s *LocalStorage) Delete(name string) error {
+	return os.Remove(filepath.Join(s.Dir, name))
+}
+
+func (s *LocalStorage) URL(name string) string {
+	return s.BaseURL + name
+}
+
+// S3Storage stores uploads in an S3 (or S3-compatible) bucket.
+type S3Storage struct {
+	Bucket string
+	Prefix string
+	Region string
+	// BaseURL is prepended to the object key to build the served URL,
+	// e.g. "https://my-bucket.s3.amazonaws.com/".
+	BaseURL string
+}
+
+// NewS3Storage returns a Storage backed by the given bucket/region. AWS
+// credentials are resolved the same way the AWS SDK always does (env vars,
+// shared config, instance role, ...).
+func NewS3Storage(bucket, region, baseURL string) *S3Storage {
+	return &S3Storage{Bucket: bucket, Region: region, BaseURL: baseURL}
+}
+
+func (s *S3Storage) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+func (s *S3Storage) Put(name string, r io.Reader) (string, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(s.Region)})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	uploader := s3manager.NewUploader(sess)
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(name), nil
+}
+
+func (s *S3Storage) Delete(name string) error {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(s.Region)})
+	if err != nil {
+		return err
+	}
+
+	svc := s3.New(sess)
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	return err
+}
+
+func (s *S3Storage) URL(name string) string {
+	if s.BaseURL != "" {
+		return s.BaseURL + s.key(name)
+	}
+	return fmt.Sprintf("https://%v.s3.amazonaws.com/%v", s.Bucket, s.key(name))
+}
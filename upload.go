@@ -0,0 +1,282 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+// storageConsumer is implemented by fields that need the configured
+// Admin.Storage to handle uploads.
+type storageConsumer interface {
+	setStorage(s Storage)
+}
+
+// uploadConfig holds the `admin:"accept=...,max_size=..."` options and
+// configured Storage shared by FileField and ImageField.
+type uploadConfig struct {
+	storage  Storage
+	accept   []string
+	maxBytes int64
+}
+
+func (u *uploadConfig) configure(options map[string]string) error {
+	if accept, ok := options["accept"]; ok {
+		u.accept = strings.Split(accept, "|")
+	}
+	if maxSize, ok := options["max_size"]; ok {
+		size, err := parseByteSize(maxSize)
+		if err != nil {
+			return err
+		}
+		u.maxBytes = size
+	}
+	return nil
+}
+
+func (u *uploadConfig) setStorage(s Storage) {
+	u.storage = s
+}
+
+// readUpload validates file against the configured size cap and accepted
+// content types (sniffed from the actual bytes, not the filename), and
+// returns its contents.
+func (u *uploadConfig) readUpload(file *multipart.FileHeader) ([]byte, error) {
+	if u.maxBytes > 0 && file.Size > u.maxBytes {
+		return nil, fmt.Errorf("admin: file %q is %v bytes, over the %v byte limit", file.Filename, file.Size, u.maxBytes)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := http.DetectContentType(data)
+	if !acceptContentType(u.accept, contentType) {
+		return nil, fmt.Errorf("admin: file %q has unsupported content type %q", file.Filename, contentType)
+	}
+
+	return data, nil
+}
+
+// acceptContentType reports whether contentType matches one of the accept
+// patterns (each either an exact MIME type, or a "type/*" wildcard). No
+// patterns means everything is accepted.
+func acceptContentType(accept []string, contentType string) bool {
+	if len(accept) == 0 {
+		return true
+	}
+
+	for _, pattern := range accept {
+		if pattern == contentType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, pattern[:len(pattern)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseByteSize parses sizes like "512KB", "5MB" or a plain byte count.
+func parseByteSize(s string) (int64, error) {
+	// Longest suffixes first, so "5MB" isn't mistaken for "5M" + "B".
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	}
+
+	for _, unit := range units {
+		if strings.HasSuffix(strings.ToUpper(s), unit.suffix) {
+			n, err := strconv.ParseInt(strings.TrimSuffix(strings.ToUpper(s), unit.suffix), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return n * unit.multiplier, nil
+		}
+	}
+
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// FileField stores an uploaded file's URL as a string, via the fields's
+// configured Storage. Accepted content types and the maximum upload size
+// are set with the `admin:"accept=...,max_size=..."` tag options.
+type FileField struct {
+	*BaseField
+	uploadConfig
+}
+
+func (f *FileField) Configure(options map[string]string) error {
+	return f.uploadConfig.configure(options)
+}
+
+func (f *FileField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "file.html", val, errStr, startRow)
+}
+
+func (f *FileField) RenderString(val interface{}) template.HTML {
+	url, _ := val.(string)
+	return template.HTML(fmt.Sprintf(`<a href="%v">%v</a>`, url, url))
+}
+
+func (f *FileField) Validate(raw string) (interface{}, error) {
+	return raw, nil
+}
+
+func (f *FileField) HandleFile(file *multipart.FileHeader) (string, error) {
+	if f.storage == nil {
+		return "", errors.New("admin: no Storage configured")
+	}
+
+	data, err := f.readUpload(file)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := uniqueName(file.Filename)
+	if err != nil {
+		return "", err
+	}
+
+	return f.storage.Put(name, bytes.NewReader(data))
+}
+
+// ImageSize is a named thumbnail size an ImageField generates on upload.
+type ImageSize struct {
+	Name   string
+	Width  uint
+	Height uint
+}
+
+// ImageField behaves like FileField, and additionally generates a JPEG
+// thumbnail for each configured Size alongside the original upload.
+type ImageField struct {
+	*BaseField
+	uploadConfig
+	Sizes []ImageSize
+}
+
+func (f *ImageField) Configure(options map[string]string) error {
+	return f.uploadConfig.configure(options)
+}
+
+func (f *ImageField) Render(w io.Writer, val interface{}, errStr string, startRow bool) {
+	renderFieldTemplate(w, f.Attrs(), "image.html", val, errStr, startRow)
+}
+
+func (f *ImageField) RenderString(val interface{}) template.HTML {
+	url, _ := val.(string)
+	return template.HTML(fmt.Sprintf(`<img src="%v" height="40">`, url))
+}
+
+func (f *ImageField) Validate(raw string) (interface{}, error) {
+	return raw, nil
+}
+
+func (f *ImageField) HandleFile(file *multipart.FileHeader) (string, error) {
+	if f.storage == nil {
+		return "", errors.New("admin: no Storage configured")
+	}
+
+	data, err := f.readUpload(file)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := uniqueName(file.Filename)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := f.storage.Put(name, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	f.generateThumbnails(name, data)
+
+	return url, nil
+}
+
+// generateThumbnails best-effort resizes img to each configured Size and
+// stores the result alongside the original. A file that can't be decoded
+// as an image (or a thumbnail that fails to store) is skipped rather than
+// failing the whole upload, since the original was already saved.
+func (f *ImageField) generateThumbnails(filename string, data []byte) {
+	if len(f.Sizes) == 0 {
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	for _, size := range f.Sizes {
+		thumb := resize.Thumbnail(size.Width, size.Height, img, resize.Lanczos3)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumb, nil); err != nil {
+			continue
+		}
+
+		f.storage.Put(thumbnailName(filename, size.Name), &buf)
+	}
+}
+
+func init() {
+	RegisterField("file", &FileField{BaseField: &BaseField{}})
+	RegisterField("image", &ImageField{BaseField: &BaseField{}})
+}
+
+// uniqueName prepends a random hex prefix to filename, so two uploads that
+// happen to share a name don't collide in Storage (and, for ImageField,
+// don't clobber each other's thumbnails).
+func uniqueName(filename string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + "_" + filename, nil
+}
+
+// thumbnailName turns "photo.jpg" + "small" into "photo_small.jpg".
+func thumbnailName(filename, sizeName string) string {
+	ext := ""
+	name := filename
+	for i := len(filename) - 1; i >= 0; i-- {
+		if filename[i] == '.' {
+			ext = filename[i:]
+			name = filename[:i]
+			break
+		}
+	}
+	return fmt.Sprintf("%v_%v%v", name, sizeName, ext)
+}
@@ -0,0 +1,47 @@
+package admin
+
+import "strconv"
+
+// parseTag splits an `admin:"key=val,key2=val2,flag"` struct tag into a map
+// of option name to value. Flags with no "=" are stored with an empty value.
+func parseTag(tag string) (map[string]string, error) {
+	options := map[string]string{}
+	if len(tag) == 0 {
+		return options, nil
+	}
+
+	for _, part := range splitTag(tag) {
+		if len(part) == 0 {
+			continue
+		}
+		key, val := part, ""
+		for i := 0; i < len(part); i++ {
+			if part[i] == '=' {
+				key, val = part[:i], part[i+1:]
+				break
+			}
+		}
+		options[key] = val
+	}
+
+	return options, nil
+}
+
+func splitTag(tag string) []string {
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(tag); i++ {
+		if tag[i] == ',' {
+			parts = append(parts, tag[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, tag[start:])
+	return parts
+}
+
+// parseInt is a small strconv.Atoi wrapper used wherever a struct tag or
+// form value needs to be read as a plain int.
+func parseInt(s string) (int, error) {
+	return strconv.Atoi(s)
+}
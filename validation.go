@@ -0,0 +1,45 @@
+package admin
+
+import "database/sql"
+
+// ModelValidator is implemented by a registered model struct to run
+// cross-field validation after every field's own Validate already passed
+// (e.g. "end date must be after start date"). The returned map's keys are
+// field names, same as the per-field error map handleSave already returns.
+type ModelValidator interface {
+	Validate(data map[string]interface{}) map[string]string
+}
+
+// BeforeSaver runs inside the save transaction before the row is written.
+// Returning an error rolls the save back.
+type BeforeSaver interface {
+	BeforeSave(tx *sql.Tx, data map[string]interface{}) error
+}
+
+// AfterSaver runs inside the save transaction after the row is written. id
+// is the primary key of the inserted/updated row.
+type AfterSaver interface {
+	AfterSave(tx *sql.Tx, id int, data map[string]interface{}) error
+}
+
+// BeforeDeleter runs inside the delete transaction before the rows are
+// removed.
+type BeforeDeleter interface {
+	BeforeDelete(tx *sql.Tx, ids []int) error
+}
+
+// AfterDeleter runs inside the delete transaction after the rows are
+// removed.
+type AfterDeleter interface {
+	AfterDelete(tx *sql.Tx, ids []int) error
+}
+
+// validate runs m's optional cross-field ModelValidator against data. It
+// returns nil if m.instance doesn't implement ModelValidator.
+func (m *model) validate(data map[string]interface{}) map[string]string {
+	validator, ok := m.instance.(ModelValidator)
+	if !ok {
+		return nil
+	}
+	return validator.Validate(data)
+}